@@ -0,0 +1,74 @@
+//go:build !race
+
+package main
+
+import (
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// maxAllocsPerHelloRequest bounds the client-side allocations for a single
+// /strings/hello round trip (acquire URI, set path/args, acquire request,
+// client.Do, read body, release). A regression here usually means a
+// contributor forgot to Release a request/response/URI, reintroduced a
+// []byte<->string conversion on the hot path, or added an allocation to
+// args encoding or body reading.
+const maxAllocsPerHelloRequest = 4
+
+func TestHelloRequestAllocs(t *testing.T) {
+	forEachTarget(t, func(t *testing.T, tg *target) {
+		allocs := testing.AllocsPerRun(100, func() {
+			uri := tg.getBaseUri()
+			uri.SetPath("/strings/hello")
+			args := uri.QueryArgs()
+			args.Add("name", "some COOL guy")
+
+			req := fasthttp.AcquireRequest()
+			req.SetURI(uri)
+			resp, err := tg.doRequest(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err.Error())
+			}
+			_ = resp.Body()
+			fasthttp.ReleaseURI(uri)
+			fasthttp.ReleaseRequest(req)
+			fasthttp.ReleaseResponse(resp)
+		})
+
+		if allocs > maxAllocsPerHelloRequest {
+			t.Errorf("got %.1f allocs/op, want <= %v", allocs, maxAllocsPerHelloRequest)
+		}
+	})
+}
+
+// maxAllocsPerBrotliDecode bounds how much decoding a brotli response may
+// allocate, so the test helpers themselves stay allocation-lean rather than
+// masking a regression in the thing they check.
+const maxAllocsPerBrotliDecode = 2
+
+func TestDecodeBrotliAllocs(t *testing.T) {
+	forEachTarget(t, func(t *testing.T, tg *target) {
+		uri := tg.getBaseUri()
+		uri.SetPath("/strings/hello")
+
+		req := fasthttp.AcquireRequest()
+		req.SetURI(uri)
+		req.Header.Set(fasthttp.HeaderAcceptEncoding, "br")
+		resp, err := tg.doRequest(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err.Error())
+		}
+		defer fasthttp.ReleaseResponse(resp)
+
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, err := decode("br", resp.Body()); err != nil {
+				t.Fatalf("failed to uncompress: %v", err.Error())
+			}
+		})
+
+		if allocs > maxAllocsPerBrotliDecode {
+			t.Errorf("got %.1f allocs/op decoding brotli, want <= %v", allocs, maxAllocsPerBrotliDecode)
+		}
+	})
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/valyala/fasthttp"
+)
+
+// TestHelloFormPost exercises an application/x-www-form-urlencoded POST to
+// /strings/hello, which is expected to echo back FormValue("name") the same
+// way the GET form does via a query arg.
+func TestHelloFormPost(t *testing.T) {
+	forEachTarget(t, func(t *testing.T, tg *target) {
+		uri := tg.getBaseUri()
+		uri.SetPath("/strings/hello")
+
+		req := fasthttp.AcquireRequest()
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetURI(uri)
+		req.Header.SetContentType("application/x-www-form-urlencoded")
+		req.SetBodyString("name=" + url.QueryEscape("some COOL guy"))
+		resp, err := tg.doRequest(req)
+
+		if err != nil {
+			t.Fatalf("request failed: %v", err.Error())
+		}
+		verifyUncompressedTextResponse(resp, "Hello, some COOL guy!", t)
+	})
+}
+
+func TestHelloFormPostEmptyName(t *testing.T) {
+	forEachTarget(t, func(t *testing.T, tg *target) {
+		uri := tg.getBaseUri()
+		uri.SetPath("/strings/hello")
+
+		req := fasthttp.AcquireRequest()
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetURI(uri)
+		req.Header.SetContentType("application/x-www-form-urlencoded")
+		req.SetBodyString("name=")
+		resp, err := tg.doRequest(req)
+
+		if err != nil {
+			t.Fatalf("request failed: %v", err.Error())
+		}
+		verifyUncompressedTextResponse(resp, "Hello, world!", t)
+	})
+}
+
+func TestHelloFormPostDuplicateNameTakesFirst(t *testing.T) {
+	forEachTarget(t, func(t *testing.T, tg *target) {
+		uri := tg.getBaseUri()
+		uri.SetPath("/strings/hello")
+
+		req := fasthttp.AcquireRequest()
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetURI(uri)
+		req.Header.SetContentType("application/x-www-form-urlencoded")
+		req.SetBodyString("name=foo&name=bar")
+		resp, err := tg.doRequest(req)
+
+		if err != nil {
+			t.Fatalf("request failed: %v", err.Error())
+		}
+		verifyUncompressedTextResponse(resp, "Hello, foo!", t)
+	})
+}
+
+func TestHelloFormPostNameMaxLength(t *testing.T) {
+	forEachTarget(t, func(t *testing.T, tg *target) {
+		uri := tg.getBaseUri()
+		uri.SetPath("/strings/hello")
+
+		// Max length should succeed.
+		maxLenName := strings.Repeat("a", 500)
+		req := fasthttp.AcquireRequest()
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetURI(uri)
+		req.Header.SetContentType("application/x-www-form-urlencoded")
+		req.SetBodyString("name=" + maxLenName)
+		resp, err := tg.doRequest(req)
+
+		if err != nil {
+			t.Fatalf("max len name request failed: %v", err.Error())
+		}
+		verifyUncompressedTextResponse(resp, fmt.Sprintf("Hello, %v!", maxLenName), t)
+
+		// Too long should fail.
+		req.Reset()
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetURI(uri)
+		req.Header.SetContentType("application/x-www-form-urlencoded")
+		req.SetBodyString("name=" + maxLenName + "a")
+		resp, err = tg.doRequest(req)
+
+		if err != nil {
+			t.Fatalf("name too long request failed: %v", err.Error())
+		}
+		gotCode := resp.StatusCode()
+		wantCode := http.StatusBadRequest
+		if gotCode != wantCode {
+			t.Errorf("invalid status code for name too long. Want: %v, got: %v", wantCode, gotCode)
+		}
+	})
+}
+
+// addFormFilePart mirrors multipart.Writer.CreateFormFile, but lets the
+// caller declare the part's Content-Type explicitly (CreateFormFile always
+// hardcodes "application/octet-stream").
+func addFormFilePart(w *multipart.Writer, fieldName, fileName, contentType string, content []byte) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, fieldName, fileName))
+	header.Set("Content-Type", contentType)
+	part, err := w.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(content)
+	return err
+}
+
+// TestFileUploadSha256 exercises a multipart/form-data upload endpoint that
+// is expected to respond with the SHA-256 of the uploaded part plus its
+// declared content type, e.g. "<hex digest> text/plain; charset=utf-8".
+func TestFileUploadSha256(t *testing.T) {
+	forEachTarget(t, func(t *testing.T, tg *target) {
+		content := []byte("the quick brown fox jumps over the lazy dog")
+		contentType := "text/plain; charset=utf-8"
+
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		if err := addFormFilePart(w, "file", "fox.txt", contentType, content); err != nil {
+			t.Fatalf("failed to build multipart body: %v", err.Error())
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("failed to close multipart writer: %v", err.Error())
+		}
+
+		uri := tg.getBaseUri()
+		uri.SetPath("/files/sha256")
+
+		req := fasthttp.AcquireRequest()
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetURI(uri)
+		req.Header.SetContentType(w.FormDataContentType())
+		req.SetBody(body.Bytes())
+		resp, err := tg.doRequest(req)
+
+		if err != nil {
+			t.Fatalf("request failed: %v", err.Error())
+		}
+		sum := sha256.Sum256(content)
+		wantBody := fmt.Sprintf("%v %v", hex.EncodeToString(sum[:]), contentType)
+		verifyUncompressedTextResponse(resp, wantBody, t)
+	})
+}
+
+// TestFileUploadSha256QuotedBoundary mirrors TestFileUploadSha256 but
+// declares the multipart boundary as a quoted Content-Type parameter
+// (`boundary="..."`), which real clients sometimes send and which the
+// unquoted form must not be the only one accepted.
+func TestFileUploadSha256QuotedBoundary(t *testing.T) {
+	forEachTarget(t, func(t *testing.T, tg *target) {
+		content := []byte("quoted boundary upload")
+		contentType := "text/plain; charset=utf-8"
+
+		body := &bytes.Buffer{}
+		w := multipart.NewWriter(body)
+		if err := addFormFilePart(w, "file", "note.txt", contentType, content); err != nil {
+			t.Fatalf("failed to build multipart body: %v", err.Error())
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("failed to close multipart writer: %v", err.Error())
+		}
+
+		uri := tg.getBaseUri()
+		uri.SetPath("/files/sha256")
+
+		req := fasthttp.AcquireRequest()
+		req.Header.SetMethod(fasthttp.MethodPost)
+		req.SetURI(uri)
+		req.Header.SetContentType(fmt.Sprintf(`multipart/form-data; boundary=%q`, w.Boundary()))
+		req.SetBody(body.Bytes())
+		resp, err := tg.doRequest(req)
+
+		if err != nil {
+			t.Fatalf("request failed: %v", err.Error())
+		}
+		sum := sha256.Sum256(content)
+		wantBody := fmt.Sprintf("%v %v", hex.EncodeToString(sum[:]), contentType)
+		verifyUncompressedTextResponse(resp, wantBody, t)
+	})
+}
@@ -3,41 +3,153 @@ package main
 import (
 	"flag"
 	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
 
 	"github.com/valyala/fasthttp"
+	"github.com/valyala/fasthttp/fasthttputil"
 )
 
-var (
-	baseUrl = flag.String("base_url", "", "The base URL (scheme + host + port), to run integration tests against. Example: http://localhost:80")
-	baseUri *fasthttp.URI
-	client  *fasthttp.Client
-)
+// stringListFlag is a flag.Value that accumulates string values across
+// repeated flag occurrences and comma-separated entries within a single
+// occurrence.
+type stringListFlag []string
 
-func setUp() {
-	flag.Parse()
+func (f *stringListFlag) String() string {
+	return strings.Join(*f, ",")
+}
 
-	if *baseUrl == "" {
-		log.Fatal("Must provide a valid base_url")
-	}
-	baseUri = fasthttp.AcquireURI()
-	err := baseUri.Parse(nil, []byte(*baseUrl))
-	if err != nil {
-		log.Fatalf("Could not parse base URL (%v): %v", *baseUrl, err.Error())
+func (f *stringListFlag) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*f = append(*f, v)
+		}
 	}
+	return nil
+}
 
-	client = &fasthttp.Client{
-		Name: "integration-tester",
-	}
+// baseUrls collects every "-base_url" flag value. The flag is repeatable and
+// also accepts a comma-separated list, so "-base_url=a,b -base_url=c" and
+// "-base_url=a -base_url=b -base_url=c" are equivalent. Only used in
+// "network" mode.
+var baseUrls stringListFlag
+
+// mode selects how requests reach the server under test. "network" dials
+// -base_url over a real socket; "inproc" drives inprocHandler directly
+// through an in-memory listener, giving hermetic tests with no port
+// allocation and allowing coverage collection of the server code.
+var mode = flag.String("mode", "network", `Execution mode: "network" or "inproc".`)
+
+func init() {
+	flag.Var(&baseUrls, "base_url", "The base URL (scheme + host + port) of a server implementation to run integration tests against. May be repeated or comma-separated to compare multiple implementations in one run. Example: http://localhost:80,http://localhost:81")
 }
 
-func getBaseUri() *fasthttp.URI {
+// inprocHandler is the handler driven by "-mode=inproc". This repository
+// tests server implementations as external processes over the network and
+// does not vendor one itself, so it's left nil here; a build that wires up a
+// specific implementation should set it from an init func before TestMain
+// runs.
+var inprocHandler fasthttp.RequestHandler
+
+// targets holds one entry per configured server implementation, each with
+// its own HostClient so that tests can fan out to every implementation under
+// test, modeled after how fasthttp.LBClient shards requests across
+// per-target HostClients.
+var targets []*target
+
+type target struct {
+	name   string
+	uri    *fasthttp.URI
+	client *fasthttp.HostClient
+}
+
+func (tg *target) getBaseUri() *fasthttp.URI {
 	uri := fasthttp.AcquireURI()
-	baseUri.CopyTo(uri)
+	tg.uri.CopyTo(uri)
 	return uri
 }
 
-func doRequest(r *fasthttp.Request) (*fasthttp.Response, error) {
+func (tg *target) doRequest(r *fasthttp.Request) (*fasthttp.Response, error) {
 	resp := fasthttp.AcquireResponse()
-	err := client.Do(r, resp)
+	err := tg.client.Do(r, resp)
 	return resp, err
 }
+
+// forEachTarget runs fn as a subtest against every configured target,
+// reporting per-target pass/fail and latency independently.
+func forEachTarget(t *testing.T, fn func(t *testing.T, tg *target)) {
+	for _, tg := range targets {
+		tg := tg
+		t.Run(tg.name, func(t *testing.T) {
+			start := time.Now()
+			defer func() { t.Logf("%s: %v", tg.name, time.Since(start)) }()
+			fn(t, tg)
+		})
+	}
+}
+
+// newInprocTarget starts inprocHandler on an in-memory listener and returns a
+// target whose client dials straight into it, mirroring how fasthttpadaptor
+// lets net/http tests drive a handler without a real listener.
+func newInprocTarget() *target {
+	if inprocHandler == nil {
+		log.Fatal("-mode=inproc requires inprocHandler to be set; this repository has no built-in server implementation to drive in-process")
+	}
+
+	ln := fasthttputil.NewInmemoryListener()
+	go func() {
+		if err := (&fasthttp.Server{Handler: inprocHandler}).Serve(ln); err != nil {
+			log.Fatalf("in-process server exited: %v", err.Error())
+		}
+	}()
+
+	uri := fasthttp.AcquireURI()
+	if err := uri.Parse(nil, []byte("http://inproc")); err != nil {
+		log.Fatalf("could not parse in-process URI: %v", err.Error())
+	}
+	return &target{
+		name: "inproc",
+		uri:  uri,
+		client: &fasthttp.HostClient{
+			Addr: "inproc",
+			Dial: func(addr string) (net.Conn, error) {
+				return ln.Dial()
+			},
+		},
+	}
+}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	switch *mode {
+	case "inproc":
+		targets = []*target{newInprocTarget()}
+	case "network":
+		if len(baseUrls) == 0 {
+			log.Fatal("Must provide at least one valid base_url")
+		}
+		for _, u := range baseUrls {
+			uri := fasthttp.AcquireURI()
+			if err := uri.Parse(nil, []byte(u)); err != nil {
+				log.Fatalf("Could not parse base URL (%v): %v", u, err.Error())
+			}
+			targets = append(targets, &target{
+				name: u,
+				uri:  uri,
+				client: &fasthttp.HostClient{
+					Addr:  string(uri.Host()),
+					IsTLS: string(uri.Scheme()) == "https",
+					Name:  "integration-tester",
+				},
+			})
+		}
+	default:
+		log.Fatalf(`Unknown -mode %q; want "network" or "inproc"`, *mode)
+	}
+
+	os.Exit(m.Run())
+}